@@ -0,0 +1,197 @@
+package gui
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/collector/autodiscovery"
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+	"github.com/DataDog/datadog-agent/pkg/status"
+	log "github.com/cihub/seelog"
+)
+
+// statusAPIResponse is the JSON equivalent of the data fed to the
+// statusHTML/checkStatus templates.
+type statusAPIResponse struct {
+	Stats map[string]interface{} `json:"stats"`
+}
+
+// checkAPIResponse is the JSON equivalent of singleCheck.tmpl, with the
+// check stats normalized the same way the JSON API normalizes everything
+// else: timestamps as RFC3339 strings, the LastError blob as an
+// already-parsed traceback array.
+type checkAPIResponse struct {
+	Name  string          `json:"name"`
+	Stats []checkStatInfo `json:"stats"`
+}
+
+// checkStatInfo is check.Stats with its JSON-blob and unix-time fields
+// normalized into plain data.
+type checkStatInfo struct {
+	CheckID         string              `json:"check_id"`
+	TotalRuns       uint64              `json:"total_runs"`
+	TotalErrors     uint64              `json:"total_errors"`
+	UpdateTimestamp string              `json:"update_timestamp,omitempty"`
+	LastSuccessDate string              `json:"last_success_date,omitempty"`
+	LastError       []map[string]string `json:"last_error,omitempty"`
+}
+
+// normalizeCheckStat normalizes a single check.Stats the way
+// checkAPIHandler needs: it's the JSON API's analogue of what
+// lastErrorTraceback/formatUnixTime do for the HTML templates.
+func normalizeCheckStat(s *check.Stats) checkStatInfo {
+	info := checkStatInfo{
+		CheckID:     string(s.CheckID),
+		TotalRuns:   s.TotalRuns,
+		TotalErrors: s.TotalErrors,
+	}
+
+	if s.UpdateTimestamp != 0 {
+		info.UpdateTimestamp = normalizeUnixTime(float64(s.UpdateTimestamp))
+	}
+	if s.LastSuccessDate != 0 {
+		info.LastSuccessDate = normalizeUnixTime(float64(s.LastSuccessDate))
+	}
+	if s.LastError != "" {
+		if traceback, e := normalizeTraceback(s.LastError); e == nil {
+			info.LastError = traceback
+		}
+	}
+
+	return info
+}
+
+// loaderErrorInfo is a single loader's error for a check, with the
+// Python-repr'd error blob split into individual message lines.
+type loaderErrorInfo struct {
+	Loader   string   `json:"loader"`
+	Messages []string `json:"messages"`
+}
+
+// errorAPIResponse is the JSON equivalent of loaderErr.tmpl, with the
+// loader errors and the config parser errors already split into records
+// instead of HTML-formatted blobs.
+type errorAPIResponse struct {
+	Name       string            `json:"name"`
+	LoaderErrs []loaderErrorInfo `json:"loader_errors"`
+	ParserErrs []ParserError     `json:"parser_errors"`
+}
+
+// normalizeLoaderErrors normalizes a single check's loader errors the way
+// errorsAPIHandler needs: it's the JSON API's analogue of pythonLoaderError.
+func normalizeLoaderErrors(loaderErrs autodiscovery.LoaderErrors) []loaderErrorInfo {
+	infos := make([]loaderErrorInfo, 0, len(loaderErrs))
+	for loader, raw := range loaderErrs {
+		messages, e := normalizeLoaderError(raw)
+		if e != nil {
+			messages = []string{raw}
+		}
+		infos = append(infos, loaderErrorInfo{Loader: loader, Messages: messages})
+	}
+	return infos
+}
+
+func init() {
+	http.HandleFunc("/api/v1/status", statusAPIHandler)
+	http.HandleFunc("/api/v1/check/", checkAPIHandler)
+	http.HandleFunc("/api/v1/errors/", errorsAPIHandler)
+}
+
+func statusAPIHandler(w http.ResponseWriter, r *http.Request) {
+	data, e := status.GetStatus()
+	if e != nil {
+		writeJSONError(w, e)
+		return
+	}
+
+	stats := make(map[string]interface{})
+	if e := json.Unmarshal(data, &stats); e != nil {
+		writeJSONError(w, e)
+		return
+	}
+
+	writeJSON(w, statusAPIResponse{Stats: stats})
+}
+
+func checkAPIHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/v1/check/")
+	stats := getAllCheckStats()[name]
+
+	infos := make([]checkStatInfo, 0, len(stats))
+	for _, s := range stats {
+		infos = append(infos, normalizeCheckStat(s))
+	}
+
+	writeJSON(w, checkAPIResponse{Name: name, Stats: infos})
+}
+
+func errorsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/v1/errors/")
+
+	parserErrs, e := parseParserErrors(name)
+	if e != nil {
+		writeJSONError(w, e)
+		return
+	}
+
+	writeJSON(w, errorAPIResponse{
+		Name:       name,
+		LoaderErrs: normalizeLoaderErrors(autodiscovery.GetLoaderErrors()[name]),
+		ParserErrs: parserErrs,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if e := json.NewEncoder(w).Encode(v); e != nil {
+		log.Errorf("GUI - Error encoding JSON response: " + e.Error())
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, e error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]string{"error": e.Error()})
+}
+
+/****** Pure data-normalization equivalents of the template helpers ******/
+
+// normalizeTraceback is the data-only equivalent of lastErrorTraceback: it
+// returns the already-parsed traceback records instead of an HTML blob.
+func normalizeTraceback(value string) ([]map[string]string, error) {
+	var lastErrorArray []map[string]string
+	if e := json.Unmarshal([]byte(value), &lastErrorArray); e != nil {
+		return nil, e
+	}
+	return lastErrorArray, nil
+}
+
+// normalizeLoaderError is the data-only equivalent of pythonLoaderError: it
+// splits the Python-repr'd list of error lines into a Go string slice
+// instead of building an HTML blob.
+func normalizeLoaderError(value string) ([]string, error) {
+	value = strings.Replace(value, "'", "\"", -1)
+	var loaderErrorArray []string
+	if e := json.Unmarshal([]byte(value), &loaderErrorArray); e != nil {
+		return nil, e
+	}
+	return loaderErrorArray, nil
+}
+
+// normalizeUnixTime is the data-only equivalent of formatUnixTime: it
+// returns an RFC3339 string instead of the template's human-readable one.
+func normalizeUnixTime(unixTime float64) string {
+	ts := strconv.FormatFloat(unixTime, 'f', -1, 64)
+	secs := strings.Split(ts, ".")
+
+	sec, _ := strconv.ParseInt(secs[0], 10, 64)
+	var nsec int64
+	if len(secs) == 2 {
+		nsec, _ = strconv.ParseInt(secs[1], 10, 64)
+	}
+
+	return time.Unix(sec, nsec).UTC().Format(time.RFC3339)
+}
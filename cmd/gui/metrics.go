@@ -0,0 +1,117 @@
+package gui
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/DataDog/datadog-agent/pkg/collector"
+	"github.com/DataDog/datadog-agent/pkg/collector/autodiscovery"
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+)
+
+// getAllCheckStats returns the latest stats for every running check
+// instance, keyed by check name, the same data renderStatus/renderCheck
+// render as HTML.
+func getAllCheckStats() map[string][]*check.Stats {
+	return collector.GetCheckStats()
+}
+
+var (
+	checkRunsDesc = prometheus.NewDesc(
+		"datadog_check_runs_total",
+		"Total number of times a check instance has run.",
+		[]string{"check", "instance"}, nil,
+	)
+	checkErrorsDesc = prometheus.NewDesc(
+		"datadog_check_errors_total",
+		"Total number of times a check instance has errored.",
+		[]string{"check", "instance"}, nil,
+	)
+	checkLastExecDesc = prometheus.NewDesc(
+		"datadog_check_last_execution_duration_seconds",
+		"Duration in seconds of the last run of a check instance.",
+		[]string{"check", "instance"}, nil,
+	)
+	checkLastErrorDesc = prometheus.NewDesc(
+		"datadog_check_last_error",
+		"1 if a check instance is currently erroring, with the error message as a label.",
+		[]string{"check", "instance", "message"}, nil,
+	)
+	loaderErrorsDesc = prometheus.NewDesc(
+		"datadog_autodiscovery_loader_errors",
+		"1 for each loader that currently fails to load a given check.",
+		[]string{"check", "loader"}, nil,
+	)
+)
+
+// checkMetricsCollector is a prometheus.Collector that pulls fresh check
+// stats and loader errors straight from the check runner and the
+// autodiscovery registry on every scrape, rather than caching them in
+// background goroutines.
+type checkMetricsCollector struct {
+	getAllStats func() map[string][]*check.Stats
+}
+
+func newCheckMetricsCollector() *checkMetricsCollector {
+	return &checkMetricsCollector{getAllStats: getAllCheckStats}
+}
+
+// Describe implements prometheus.Collector.
+func (c *checkMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- checkRunsDesc
+	ch <- checkErrorsDesc
+	ch <- checkLastExecDesc
+	ch <- checkLastErrorDesc
+	ch <- loaderErrorsDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *checkMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, stats := range c.getAllStats() {
+		for _, s := range stats {
+			instance := s.CheckID
+			ch <- prometheus.MustNewConstMetric(checkRunsDesc, prometheus.CounterValue, float64(s.TotalRuns), name, string(instance))
+			ch <- prometheus.MustNewConstMetric(checkErrorsDesc, prometheus.CounterValue, float64(s.TotalErrors), name, string(instance))
+			ch <- prometheus.MustNewConstMetric(checkLastExecDesc, prometheus.GaugeValue, s.LastExecutionTime.Seconds(), name, string(instance))
+
+			if s.LastError != "" {
+				ch <- prometheus.MustNewConstMetric(checkLastErrorDesc, prometheus.GaugeValue, 1, name, string(instance), checkErrorMessage(s.LastError))
+			}
+		}
+	}
+
+	for name, loaderErrs := range autodiscovery.GetLoaderErrors() {
+		for loader := range loaderErrs {
+			ch <- prometheus.MustNewConstMetric(loaderErrorsDesc, prometheus.GaugeValue, 1, name, loader)
+		}
+	}
+}
+
+// checkErrorMessage extracts the plain error message out of LastError's
+// JSON-encoded `[{"message":...,"traceback":...}]` blob, the same blob
+// lastErrorMessage unpacks for the HTML templates, so this label doesn't
+// end up being the raw unparsed blob.
+func checkErrorMessage(lastError string) string {
+	lastErrorArray, err := normalizeTraceback(lastError)
+	if err != nil || len(lastErrorArray) == 0 {
+		return "UNKNOWN ERROR"
+	}
+	if message, ok := lastErrorArray[0]["message"]; ok {
+		return message
+	}
+	return "UNKNOWN ERROR"
+}
+
+// metricsHandler builds a fresh CollectorRegistry on every call, wired to
+// the live check runner, and returns the standard promhttp handler for it.
+func metricsHandler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newCheckMetricsCollector())
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func init() {
+	http.Handle("/metrics", metricsHandler())
+}
@@ -97,24 +97,59 @@ func renderError(name string) (string, error) {
 
 	loaderErrs := autodiscovery.GetLoaderErrors()
 
-	// Check if there's a message in the log indicating the config file had an error
+	parserErrs, e := parseParserErrors(name)
+	if e != nil {
+		return "", e
+	}
+
 	parserErr := ""
+	for _, pe := range parserErrs {
+		parserErr += pe.Message
+	}
+
+	errs := Errors{name, loaderErrs, parserErr}
+	e = t.Execute(b, errs)
+	if e != nil {
+		return "", e
+	}
+	return b.String(), nil
+}
+
+// ParserError is a single line of a config parsing error, as scanned out of
+// the agent's own log file.
+type ParserError struct {
+	Line    int
+	Message string
+}
+
+// parseParserErrors scans the agent's log file for the message logged when
+// name's config file fails to parse, and returns it as structured records
+// instead of a concatenated string blob, so callers other than the HTML
+// templates (e.g. the JSON API) don't have to re-parse it.
+func parseParserErrors(name string) ([]ParserError, error) {
+	var errs []ParserError
+
 	logFile, e := os.Open(config.Datadog.GetString("log_file"))
 	if e != nil {
 		log.Errorf("GUI - Error reading log file: " + e.Error())
-		return "", e
+		return nil, e
 	}
+	defer logFile.Close()
+
 	scanner := bufio.NewScanner(logFile)
+	lineNum := 0
 	for scanner.Scan() {
+		lineNum++
 		if strings.Contains(scanner.Text(), name+".yaml is not a valid config file:") {
-			parserErr = scanner.Text()
+			errs = append(errs, ParserError{Line: lineNum, Message: scanner.Text()})
 
 			// Get the lines containing the error
 			for scanner.Scan() {
+				lineNum++
 				if !(strings.Contains(scanner.Text(), "| WARN |") ||
 					strings.Contains(scanner.Text(), "| INFO |") ||
 					strings.Contains(scanner.Text(), "| ERROR |")) {
-					parserErr += scanner.Text()
+					errs = append(errs, ParserError{Line: lineNum, Message: scanner.Text()})
 				} else {
 					break
 				}
@@ -122,12 +157,7 @@ func renderError(name string) (string, error) {
 		}
 	}
 
-	errs := Errors{name, loaderErrs, parserErr}
-	e = t.Execute(b, errs)
-	if e != nil {
-		return "", e
-	}
-	return b.String(), nil
+	return errs, nil
 }
 
 /****** Helper functions for the template formatting ******/
@@ -0,0 +1,197 @@
+package gui
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/DataDog/datadog-agent/pkg/collector/autodiscovery"
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+	log "github.com/cihub/seelog"
+)
+
+// statusUpdateInterval is how often the hub polls the check runner and the
+// loader-error registry for changes and coalesces them into a single
+// update, so a burst of check runs doesn't turn into a burst of messages.
+const statusUpdateInterval = 500 * time.Millisecond
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// The GUI is only ever served on localhost; allow it to connect back
+	// to itself regardless of the Origin header it sends.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// statusUpdate is the JSON diff pushed to subscribers. RemovedChecks and
+// RemovedLoaderErrs are tombstones: check names that were present at the
+// previous poll but are now gone, so subscribers can evict them instead
+// of holding onto stale stats forever.
+type statusUpdate struct {
+	Stats             map[string][]*check.Stats             `json:"stats,omitempty"`
+	LoaderErrs        map[string]autodiscovery.LoaderErrors `json:"loader_errors,omitempty"`
+	RemovedChecks     []string                              `json:"removed_checks,omitempty"`
+	RemovedLoaderErrs []string                              `json:"removed_loader_errors,omitempty"`
+}
+
+// statusHub is a small pub/sub hub: it polls for changes on a tick and fans
+// out a diff to every connected browser.
+type statusHub struct {
+	m           sync.Mutex
+	subscribers map[chan statusUpdate]bool
+	lastStats   map[string][]*check.Stats
+	lastErrs    map[string]autodiscovery.LoaderErrors
+	stop        chan bool
+}
+
+var hub = newStatusHub()
+
+func newStatusHub() *statusHub {
+	h := &statusHub{
+		subscribers: make(map[chan statusUpdate]bool),
+		stop:        make(chan bool),
+	}
+	go h.run()
+	return h
+}
+
+func (h *statusHub) run() {
+	ticker := time.NewTicker(statusUpdateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			h.poll()
+		}
+	}
+}
+
+// poll pulls the current state from the check runner and the
+// autodiscovery loader-error registry, and fans out only the checks whose
+// stats or loader errors actually changed since the last tick, rather than
+// the whole map whenever anything in it moved.
+func (h *statusHub) poll() {
+	stats := getAllCheckStats()
+	loaderErrs := autodiscovery.GetLoaderErrors()
+
+	statsDiff, statsRemoved := diffStats(h.lastStats, stats)
+	loaderErrsDiff, loaderErrsRemoved := diffLoaderErrs(h.lastErrs, loaderErrs)
+	h.lastStats = stats
+	h.lastErrs = loaderErrs
+
+	if len(statsDiff) == 0 && len(loaderErrsDiff) == 0 && len(statsRemoved) == 0 && len(loaderErrsRemoved) == 0 {
+		return
+	}
+
+	update := statusUpdate{
+		RemovedChecks:     statsRemoved,
+		RemovedLoaderErrs: loaderErrsRemoved,
+	}
+	if len(statsDiff) > 0 {
+		update.Stats = statsDiff
+	}
+	if len(loaderErrsDiff) > 0 {
+		update.LoaderErrs = loaderErrsDiff
+	}
+
+	h.broadcast(update)
+}
+
+// diffStats returns the checks whose stats changed between two polls, and
+// the names of checks that disappeared from cur entirely so subscribers
+// can evict them instead of keeping stale stats around.
+func diffStats(last, cur map[string][]*check.Stats) (diff map[string][]*check.Stats, removed []string) {
+	diff = make(map[string][]*check.Stats)
+	for name, stats := range cur {
+		if !reflect.DeepEqual(stats, last[name]) {
+			diff[name] = stats
+		}
+	}
+	for name := range last {
+		if _, found := cur[name]; !found {
+			removed = append(removed, name)
+		}
+	}
+	return diff, removed
+}
+
+// diffLoaderErrs returns the checks whose loader errors changed between
+// two polls, and the names of checks whose loader errors disappeared
+// entirely.
+func diffLoaderErrs(last, cur map[string]autodiscovery.LoaderErrors) (diff map[string]autodiscovery.LoaderErrors, removed []string) {
+	diff = make(map[string]autodiscovery.LoaderErrors)
+	for name, errs := range cur {
+		if !reflect.DeepEqual(errs, last[name]) {
+			diff[name] = errs
+		}
+	}
+	for name := range last {
+		if _, found := cur[name]; !found {
+			removed = append(removed, name)
+		}
+	}
+	return diff, removed
+}
+
+func (h *statusHub) broadcast(update statusUpdate) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	for sub := range h.subscribers {
+		select {
+		case sub <- update:
+		default:
+			// Subscriber is too slow to keep up; drop the update rather
+			// than block the hub.
+		}
+	}
+}
+
+func (h *statusHub) subscribe() chan statusUpdate {
+	sub := make(chan statusUpdate, 1)
+
+	h.m.Lock()
+	h.subscribers[sub] = true
+	h.m.Unlock()
+
+	return sub
+}
+
+func (h *statusHub) unsubscribe(sub chan statusUpdate) {
+	h.m.Lock()
+	delete(h.subscribers, sub)
+	h.m.Unlock()
+
+	close(sub)
+}
+
+// wsStatusHandler upgrades the connection and streams statusUpdate diffs
+// until the browser disconnects. The initial page is still rendered by
+// renderStatus/renderCheck; only subsequent updates arrive over the socket.
+func wsStatusHandler(w http.ResponseWriter, r *http.Request) {
+	conn, e := upgrader.Upgrade(w, r, nil)
+	if e != nil {
+		log.Errorf("GUI - Error upgrading websocket connection: " + e.Error())
+		return
+	}
+	defer conn.Close()
+
+	sub := hub.subscribe()
+	defer hub.unsubscribe(sub)
+
+	for update := range sub {
+		if e := conn.WriteJSON(update); e != nil {
+			return
+		}
+	}
+}
+
+func init() {
+	http.HandleFunc("/ws/status", wsStatusHandler)
+}
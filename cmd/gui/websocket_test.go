@@ -0,0 +1,69 @@
+package gui
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/collector/autodiscovery"
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+)
+
+func TestDiffStatsReportsChangedAndOmitsUnchanged(t *testing.T) {
+	last := map[string][]*check.Stats{
+		"redisdb": {{CheckID: "redisdb:1"}},
+		"ntp":     {{CheckID: "ntp:1"}},
+	}
+	cur := map[string][]*check.Stats{
+		"redisdb": {{CheckID: "redisdb:1", TotalRuns: 1}}, // changed
+		"ntp":     {{CheckID: "ntp:1"}},                   // unchanged
+	}
+
+	diff, removed := diffStats(last, cur)
+
+	if _, ok := diff["redisdb"]; !ok {
+		t.Fatal("expected redisdb to be reported as changed")
+	}
+	if _, ok := diff["ntp"]; ok {
+		t.Fatal("expected unchanged ntp to be omitted from the diff")
+	}
+	if len(removed) != 0 {
+		t.Fatalf("expected no removals, got %v", removed)
+	}
+}
+
+func TestDiffStatsReportsRemovedChecks(t *testing.T) {
+	last := map[string][]*check.Stats{
+		"redisdb": {{CheckID: "redisdb:1"}},
+		"ntp":     {{CheckID: "ntp:1"}},
+	}
+	cur := map[string][]*check.Stats{
+		"ntp": {{CheckID: "ntp:1"}},
+	}
+
+	diff, removed := diffStats(last, cur)
+
+	if len(diff) != 0 {
+		t.Fatalf("expected no changes, got %v", diff)
+	}
+	if len(removed) != 1 || removed[0] != "redisdb" {
+		t.Fatalf("expected redisdb to be reported as removed, got %v", removed)
+	}
+}
+
+func TestDiffLoaderErrsReportsChangedAndRemoved(t *testing.T) {
+	last := map[string]autodiscovery.LoaderErrors{
+		"redisdb": {"python": "['err1']"},
+		"ntp":     {"python": "['err2']"},
+	}
+	cur := map[string]autodiscovery.LoaderErrors{
+		"redisdb": {"python": "['err1-updated']"},
+	}
+
+	diff, removed := diffLoaderErrs(last, cur)
+
+	if _, ok := diff["redisdb"]; !ok {
+		t.Fatal("expected redisdb to be reported as changed")
+	}
+	if len(removed) != 1 || removed[0] != "ntp" {
+		t.Fatalf("expected ntp to be reported as removed, got %v", removed)
+	}
+}
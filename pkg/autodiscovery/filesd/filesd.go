@@ -0,0 +1,206 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// Package filesd turns the agent's autodiscovery into a Prometheus
+// file_sd_config source, so Prometheus can scrape the same services the
+// agent schedules checks against without any extra integration code on the
+// Prometheus side.
+package filesd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/listeners"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// target is a single file_sd_config entry.
+type target struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// Exporter consumes the newSvc/delSvc channels fed by one or more
+// listeners.ServiceListener and keeps a Prometheus file_sd file in sync
+// with the services it's been told about.
+type Exporter struct {
+	path   string
+	newSvc chan listeners.Service
+	delSvc chan listeners.Service
+	stop   chan bool
+
+	m       sync.Mutex
+	targets map[listeners.ID]target
+}
+
+// NewExporter returns an Exporter that writes its file_sd file to path.
+func NewExporter(path string) *Exporter {
+	return &Exporter{
+		path:    path,
+		newSvc:  make(chan listeners.Service),
+		delSvc:  make(chan listeners.Service),
+		stop:    make(chan bool),
+		targets: make(map[listeners.ID]target),
+	}
+}
+
+// Listen hooks listener's events into the exporter. It may be called once
+// per configured listener (docker, consul, file, ...): every listener
+// shares the exporter's channels, and targets are keyed by GetID() alone,
+// so a service whose ID equals one already held, from any listener,
+// replaces it.
+func (e *Exporter) Listen(listener listeners.ServiceListener) {
+	listener.Listen(e.newSvc, e.delSvc)
+}
+
+// Run processes service events until Stop is called, rewriting the file_sd
+// file after every change.
+func (e *Exporter) Run() {
+	for {
+		select {
+		case svc := <-e.newSvc:
+			e.addService(svc)
+		case svc := <-e.delSvc:
+			e.removeService(svc)
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// Stop stops processing service events.
+func (e *Exporter) Stop() {
+	e.stop <- true
+}
+
+func (e *Exporter) addService(svc listeners.Service) {
+	t, err := toTarget(svc)
+	if err != nil {
+		log.Warnf("file_sd exporter: skipping service %s: %s", svc.GetID(), err)
+		return
+	}
+
+	e.m.Lock()
+	e.targets[svc.GetID()] = t
+	e.m.Unlock()
+
+	e.write()
+}
+
+func (e *Exporter) removeService(svc listeners.Service) {
+	e.m.Lock()
+	delete(e.targets, svc.GetID())
+	e.m.Unlock()
+
+	e.write()
+}
+
+// toTarget builds a file_sd target from a Service, one "host:port" entry
+// per host/port combination it exposes.
+func toTarget(svc listeners.Service) (target, error) {
+	hosts, err := svc.GetHosts()
+	if err != nil {
+		return target{}, err
+	}
+	ports, err := svc.GetPorts()
+	if err != nil {
+		return target{}, err
+	}
+	if len(hosts) == 0 || len(ports) == 0 {
+		return target{}, fmt.Errorf("service has no hosts or ports")
+	}
+
+	var targets []string
+	for _, host := range hosts {
+		for _, port := range ports {
+			targets = append(targets, fmt.Sprintf("%s:%d", host, port.Port))
+		}
+	}
+
+	labels := map[string]string{
+		"__meta_datadog_service_id": string(svc.GetID()),
+	}
+
+	if adIDs, err := svc.GetADIdentifiers(); err == nil && len(adIDs) > 0 {
+		labels["__meta_datadog_ad_identifier"] = strings.Join(adIDs, ",")
+	}
+	if hostname, err := svc.GetHostname(); err == nil && hostname != "" {
+		labels["__meta_datadog_hostname"] = hostname
+	}
+	if tags, err := svc.GetTags(); err == nil {
+		for _, tag := range tags {
+			key, value := splitTag(tag)
+			labels["__meta_datadog_tag_"+key] = value
+		}
+	}
+
+	return target{Targets: targets, Labels: labels}, nil
+}
+
+// splitTag splits a "key:value" tag into its key and value; a tag with no
+// colon is treated as a boolean-ish key with an empty value.
+func splitTag(tag string) (string, string) {
+	parts := strings.SplitN(tag, ":", 2)
+	if len(parts) == 2 {
+		return sanitizeLabelName(parts[0]), parts[1]
+	}
+	return sanitizeLabelName(parts[0]), ""
+}
+
+// sanitizeLabelName makes a tag key safe to use as a Prometheus label name.
+func sanitizeLabelName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// write atomically rewrites the file_sd file with the current set of
+// targets, so Prometheus never observes a partially-written file.
+func (e *Exporter) write() {
+	e.m.Lock()
+	targets := make([]target, 0, len(e.targets))
+	for _, t := range e.targets {
+		targets = append(targets, t)
+	}
+	e.m.Unlock()
+
+	data, err := json.MarshalIndent(targets, "", "  ")
+	if err != nil {
+		log.Errorf("file_sd exporter: unable to marshal targets: %s", err)
+		return
+	}
+
+	dir := filepath.Dir(e.path)
+	tmp, err := ioutil.TempFile(dir, ".file_sd-"+strconv.Itoa(os.Getpid()))
+	if err != nil {
+		log.Errorf("file_sd exporter: unable to create temp file: %s", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		log.Errorf("file_sd exporter: unable to write temp file: %s", err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		log.Errorf("file_sd exporter: unable to close temp file: %s", err)
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), e.path); err != nil {
+		log.Errorf("file_sd exporter: unable to rename temp file into place: %s", err)
+	}
+}
@@ -0,0 +1,253 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package listeners
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	consul "github.com/hashicorp/consul/api"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	defaultConsulADTagPrefix = "dd-check:"
+	consulMinBackoff         = 1 * time.Second
+	consulMaxBackoff         = 60 * time.Second
+	consulWaitTime           = 5 * time.Minute
+)
+
+// ConsulListener implements ServiceListener by watching the Consul catalog
+// and the health of the services it contains.
+type ConsulListener struct {
+	client      *consul.Client
+	newSvc      chan<- Service
+	delSvc      chan<- Service
+	cancel      context.CancelFunc
+	m           sync.RWMutex
+	services    map[ID]*ConsulService
+	datacenter  string
+	adTagPrefix string
+}
+
+// ConsulService implements the Service interface for a Consul-registered
+// service instance.
+type ConsulService struct {
+	ID          ID
+	Name        string
+	Tags        []string
+	Address     string
+	Port        int
+	ServiceNode string
+	adTagPrefix string
+}
+
+// NewConsulListener returns a new ConsulListener backed by a Consul api
+// client configured from config.Datadog.
+func NewConsulListener() (ServiceListener, error) {
+	clientConfig := consul.DefaultConfig()
+
+	if addr := config.Datadog.GetString("consul_agent_url"); addr != "" {
+		clientConfig.Address = addr
+	}
+	if token := config.Datadog.GetString("consul_token"); token != "" {
+		clientConfig.Token = token
+	}
+	if dc := config.Datadog.GetString("consul_datacenter"); dc != "" {
+		clientConfig.Datacenter = dc
+	}
+
+	client, err := consul.NewClient(clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Consul client: %s", err)
+	}
+
+	adTagPrefix := config.Datadog.GetString("consul_ad_tag_prefix")
+	if adTagPrefix == "" {
+		adTagPrefix = defaultConsulADTagPrefix
+	}
+
+	return &ConsulListener{
+		client:      client,
+		services:    make(map[ID]*ConsulService),
+		datacenter:  clientConfig.Datacenter,
+		adTagPrefix: adTagPrefix,
+	}, nil
+}
+
+// Listen starts watching the Consul catalog for changes.
+func (l *ConsulListener) Listen(newSvc, delSvc chan<- Service) {
+	l.newSvc = newSvc
+	l.delSvc = delSvc
+
+	var ctx context.Context
+	ctx, l.cancel = context.WithCancel(context.Background())
+
+	go l.watch(ctx)
+}
+
+// Stop cancels the in-flight Consul query, if any, and stops the watch
+// loop. Without canceling the context, Stop could block the caller for up
+// to consulWaitTime while a long-poll is in flight.
+func (l *ConsulListener) Stop() {
+	l.cancel()
+}
+
+// watch blocking-queries the catalog for the list of services, then
+// queries (without blocking) the health of each one, reconciling the listener's cache
+// after every pass. Errors back off exponentially up to consulMaxBackoff.
+// It returns as soon as ctx is canceled, even with a query in flight.
+func (l *ConsulListener) watch(ctx context.Context) {
+	var catalogIndex uint64
+	backoff := consulMinBackoff
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		services, meta, err := l.client.Catalog().Services((&consul.QueryOptions{
+			WaitIndex: catalogIndex,
+			WaitTime:  consulWaitTime,
+		}).WithContext(ctx))
+		if err != nil {
+			if ctx.Err() != nil {
+				// Stop() canceled us; no need to back off or keep polling.
+				return
+			}
+			log.Warnf("Consul listener: error querying catalog: %s", err)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			backoff *= 2
+			if backoff > consulMaxBackoff {
+				backoff = consulMaxBackoff
+			}
+			continue
+		}
+		backoff = consulMinBackoff
+		catalogIndex = meta.LastIndex
+
+		seen := make(map[ID]*ConsulService)
+		for name := range services {
+			entries, _, err := l.client.Health().Service(name, "", true, (&consul.QueryOptions{}).WithContext(ctx))
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Warnf("Consul listener: error querying health for service %s: %s", name, err)
+				continue
+			}
+			for _, entry := range entries {
+				svc := l.toConsulService(name, entry)
+				seen[svc.ID] = svc
+			}
+		}
+
+		l.reconcile(seen)
+	}
+}
+
+// reconcile diffs the freshly observed services against the cache and emits
+// newSvc/delSvc events only for what actually changed, so unchanged
+// instances don't generate spurious events on every poll.
+func (l *ConsulListener) reconcile(seen map[ID]*ConsulService) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	for id, svc := range seen {
+		old, found := l.services[id]
+		if found && reflect.DeepEqual(old, svc) {
+			continue
+		}
+		l.services[id] = svc
+		if found {
+			l.delSvc <- old
+		}
+		l.newSvc <- svc
+	}
+
+	for id, svc := range l.services {
+		if _, found := seen[id]; !found {
+			delete(l.services, id)
+			l.delSvc <- svc
+		}
+	}
+}
+
+func (l *ConsulListener) toConsulService(name string, entry *consul.ServiceEntry) *ConsulService {
+	address := entry.Service.Address
+	if address == "" {
+		address = entry.Node.Address
+	}
+
+	return &ConsulService{
+		ID:          ID(fmt.Sprintf("consul://%s/%s", entry.Node.Node, entry.Service.ID)),
+		Name:        name,
+		Tags:        entry.Service.Tags,
+		Address:     address,
+		Port:        entry.Service.Port,
+		ServiceNode: entry.Node.Node,
+		adTagPrefix: l.adTagPrefix,
+	}
+}
+
+// GetID returns the unique identifier of the Consul service instance.
+func (s *ConsulService) GetID() ID {
+	return s.ID
+}
+
+// GetADIdentifiers returns the service name plus any tags matching the
+// `consul_ad_tag_prefix`-configured prefix (`dd-check:` by default), so
+// templates can be matched against either the service name or an explicit
+// check name.
+func (s *ConsulService) GetADIdentifiers() ([]string, error) {
+	ids := []string{s.Name}
+	for _, tag := range s.Tags {
+		if strings.HasPrefix(tag, s.adTagPrefix) {
+			ids = append(ids, strings.TrimPrefix(tag, s.adTagPrefix))
+		}
+	}
+	return ids, nil
+}
+
+// GetHosts returns the Consul service's address, keyed by "consul" since
+// Consul does not expose a notion of multiple networks per instance.
+func (s *ConsulService) GetHosts() (map[string]string, error) {
+	return map[string]string{"consul": s.Address}, nil
+}
+
+// GetPorts returns the Consul service's registered port.
+func (s *ConsulService) GetPorts() ([]ContainerPort, error) {
+	return []ContainerPort{{Port: s.Port, Name: ""}}, nil
+}
+
+// GetTags returns the raw tags attached to the Consul service.
+func (s *ConsulService) GetTags() ([]string, error) {
+	return s.Tags, nil
+}
+
+// GetPid is not supported for Consul services.
+func (s *ConsulService) GetPid() (int, error) {
+	return -1, ErrNotSupported
+}
+
+// GetHostname returns the Consul node name the service is running on.
+func (s *ConsulService) GetHostname() (string, error) {
+	return s.ServiceNode, nil
+}
+
+func init() {
+	Register("consul", NewConsulListener)
+}
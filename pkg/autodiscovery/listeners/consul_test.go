@@ -0,0 +1,117 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package listeners
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestConsulListener() (*ConsulListener, chan Service, chan Service) {
+	newSvc := make(chan Service, 10)
+	delSvc := make(chan Service, 10)
+
+	return &ConsulListener{
+		newSvc:      newSvc,
+		delSvc:      delSvc,
+		services:    make(map[ID]*ConsulService),
+		adTagPrefix: defaultConsulADTagPrefix,
+	}, newSvc, delSvc
+}
+
+func TestConsulReconcileEmitsNewService(t *testing.T) {
+	l, newSvc, _ := newTestConsulListener()
+	svc := &ConsulService{ID: "consul://node/svc1", Name: "redis"}
+
+	l.reconcile(map[ID]*ConsulService{svc.ID: svc})
+
+	select {
+	case got := <-newSvc:
+		if got.GetID() != svc.ID {
+			t.Fatalf("expected newSvc for %s, got %s", svc.ID, got.GetID())
+		}
+	default:
+		t.Fatal("expected a newSvc event, got none")
+	}
+}
+
+func TestConsulReconcileIsIdempotent(t *testing.T) {
+	l, newSvc, delSvc := newTestConsulListener()
+	svc := &ConsulService{ID: "consul://node/svc1", Name: "redis"}
+
+	l.reconcile(map[ID]*ConsulService{svc.ID: svc})
+	<-newSvc // drain the first event
+
+	// Reconciling the same set again should not emit a second newSvc, since
+	// the instance is unchanged.
+	l.reconcile(map[ID]*ConsulService{svc.ID: svc})
+
+	select {
+	case got := <-newSvc:
+		t.Fatalf("expected no further newSvc event, got %s", got.GetID())
+	case got := <-delSvc:
+		t.Fatalf("expected no delSvc event, got %s", got.GetID())
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestConsulReconcileEmitsDelForRemovedService(t *testing.T) {
+	l, newSvc, delSvc := newTestConsulListener()
+	svc := &ConsulService{ID: "consul://node/svc1", Name: "redis"}
+
+	l.reconcile(map[ID]*ConsulService{svc.ID: svc})
+	<-newSvc
+
+	l.reconcile(map[ID]*ConsulService{})
+
+	select {
+	case got := <-delSvc:
+		if got.GetID() != svc.ID {
+			t.Fatalf("expected delSvc for %s, got %s", svc.ID, got.GetID())
+		}
+	default:
+		t.Fatal("expected a delSvc event, got none")
+	}
+}
+
+func TestConsulServiceGetADIdentifiers(t *testing.T) {
+	svc := &ConsulService{
+		Name:        "redis",
+		Tags:        []string{"dd-check:redisdb", "env:prod"},
+		adTagPrefix: "dd-check:",
+	}
+
+	ids, err := svc.GetADIdentifiers()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []string{"redis", "redisdb"}
+	if len(ids) != len(want) {
+		t.Fatalf("expected %v, got %v", want, ids)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, ids)
+		}
+	}
+}
+
+func TestConsulServiceGetADIdentifiersCustomPrefix(t *testing.T) {
+	svc := &ConsulService{
+		Name:        "redis",
+		Tags:        []string{"check-me:redisdb"},
+		adTagPrefix: "check-me:",
+	}
+
+	ids, err := svc.GetADIdentifiers()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(ids) != 2 || ids[1] != "redisdb" {
+		t.Fatalf("expected the custom prefix to be honored, got %v", ids)
+	}
+}
@@ -0,0 +1,240 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package listeners
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/fsnotify.v1"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// FileListener implements ServiceListener by watching a directory of
+// YAML/JSON files, each describing a single static service. It gives
+// operators a git-managed service source that composes with the other
+// listeners, mirroring the file_sd pattern from Prometheus.
+type FileListener struct {
+	dir     string
+	watcher *fsnotify.Watcher
+	newSvc  chan<- Service
+	delSvc  chan<- Service
+	cancel  context.CancelFunc
+	m       sync.RWMutex
+	// services maps a service file's path to the service it last described,
+	// so a delete or re-write can emit the right event.
+	services map[string]*FileService
+}
+
+// FileService implements the Service interface from the contents of a
+// single service file.
+type FileService struct {
+	FileID    ID                `yaml:"id" json:"id"`
+	FileADIDs []string          `yaml:"ad_identifiers" json:"ad_identifiers"`
+	FileHosts map[string]string `yaml:"hosts" json:"hosts"`
+	FilePorts []ContainerPort   `yaml:"ports" json:"ports"`
+	FileTags  []string          `yaml:"tags" json:"tags"`
+	FilePid   int               `yaml:"pid" json:"pid"`
+	FileHost  string            `yaml:"hostname" json:"hostname"`
+}
+
+// NewFileListener returns a new FileListener watching the directory
+// configured via `autoconf_service_dir`.
+func NewFileListener() (ServiceListener, error) {
+	dir := config.Datadog.GetString("autoconf_service_dir")
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	return &FileListener{
+		dir:      dir,
+		watcher:  watcher,
+		services: make(map[string]*FileService),
+	}, nil
+}
+
+// Listen starts watching the configured directory, emitting an initial
+// newSvc for every service file already present.
+func (l *FileListener) Listen(newSvc, delSvc chan<- Service) {
+	l.newSvc = newSvc
+	l.delSvc = delSvc
+
+	var ctx context.Context
+	ctx, l.cancel = context.WithCancel(context.Background())
+
+	files, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		log.Errorf("File listener: unable to read %s: %s", l.dir, err)
+	} else {
+		for _, f := range files {
+			if f.IsDir() {
+				continue
+			}
+			l.loadAndNotify(ctx, filepath.Join(l.dir, f.Name()))
+		}
+	}
+
+	go l.watch(ctx)
+}
+
+// Stop cancels the listener's context and closes the filesystem watch.
+// Canceling first ensures watch (and any loadAndNotify/removeAndNotify
+// currently blocked sending on newSvc/delSvc) returns instead of hanging
+// if the autodiscovery consumer is slow or already shutting down.
+func (l *FileListener) Stop() {
+	l.cancel()
+	l.watcher.Close()
+}
+
+func (l *FileListener) watch(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-l.watcher.Events:
+			if !ok {
+				return
+			}
+			switch {
+			case event.Op&(fsnotify.Create|fsnotify.Write) != 0:
+				l.loadAndNotify(ctx, event.Name)
+			case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+				l.removeAndNotify(ctx, event.Name)
+			}
+		case err, ok := <-l.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warnf("File listener: watcher error: %s", err)
+		}
+	}
+}
+
+// loadAndNotify parses a service file and emits newSvc. If the file already
+// described a service, the previous version is removed first so a modify
+// triggers a full reconciliation of the config it resolved to. Both sends
+// are raced against ctx so a slow or stopped consumer can't hang Stop().
+func (l *FileListener) loadAndNotify(ctx context.Context, path string) {
+	svc, err := parseServiceFile(path)
+	if err != nil {
+		log.Errorf("File listener: unable to parse %s: %s", path, err)
+		return
+	}
+
+	l.m.Lock()
+	old, found := l.services[path]
+	l.services[path] = svc
+	l.m.Unlock()
+
+	if found {
+		select {
+		case l.delSvc <- old:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	select {
+	case l.newSvc <- svc:
+	case <-ctx.Done():
+	}
+}
+
+func (l *FileListener) removeAndNotify(ctx context.Context, path string) {
+	l.m.Lock()
+	svc, found := l.services[path]
+	delete(l.services, path)
+	l.m.Unlock()
+
+	if !found {
+		return
+	}
+
+	select {
+	case l.delSvc <- svc:
+	case <-ctx.Done():
+	}
+}
+
+func parseServiceFile(path string) (*FileService, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := &FileService{}
+	if strings.HasSuffix(path, ".json") {
+		err = json.Unmarshal(data, svc)
+	} else {
+		err = yaml.Unmarshal(data, svc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if svc.FileID == "" {
+		svc.FileID = ID(path)
+	}
+	return svc, nil
+}
+
+// GetID returns the service's configured id, or its file path if none was
+// given.
+func (s *FileService) GetID() ID {
+	return s.FileID
+}
+
+// GetADIdentifiers returns the ad_identifiers declared in the service file.
+func (s *FileService) GetADIdentifiers() ([]string, error) {
+	return s.FileADIDs, nil
+}
+
+// GetHosts returns the hosts declared in the service file.
+func (s *FileService) GetHosts() (map[string]string, error) {
+	return s.FileHosts, nil
+}
+
+// GetPorts returns the ports declared in the service file.
+func (s *FileService) GetPorts() ([]ContainerPort, error) {
+	return s.FilePorts, nil
+}
+
+// GetTags returns the tags declared in the service file.
+func (s *FileService) GetTags() ([]string, error) {
+	return s.FileTags, nil
+}
+
+// GetPid returns the pid declared in the service file, if any.
+func (s *FileService) GetPid() (int, error) {
+	if s.FilePid == 0 {
+		return -1, ErrNotSupported
+	}
+	return s.FilePid, nil
+}
+
+// GetHostname returns the hostname declared in the service file.
+func (s *FileService) GetHostname() (string, error) {
+	if s.FileHost == "" {
+		return "", ErrNotSupported
+	}
+	return s.FileHost, nil
+}
+
+func init() {
+	Register("file", NewFileListener)
+}
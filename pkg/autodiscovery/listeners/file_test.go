@@ -0,0 +1,180 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package listeners
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeServiceFile(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("unable to write service file: %s", err)
+	}
+	return path
+}
+
+func TestParseServiceFileYAML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filelistener")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeServiceFile(t, dir, "redis.yaml", `
+id: my-redis
+ad_identifiers: ["redisdb"]
+hosts: {main: "10.0.0.1"}
+ports: [{port: 6379}]
+tags: ["env:prod"]
+`)
+
+	svc, err := parseServiceFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if svc.FileID != "my-redis" {
+		t.Fatalf("expected id my-redis, got %s", svc.FileID)
+	}
+	if svc.FileHosts["main"] != "10.0.0.1" {
+		t.Fatalf("expected host 10.0.0.1, got %v", svc.FileHosts)
+	}
+	if len(svc.FilePorts) != 1 || svc.FilePorts[0].Port != 6379 {
+		t.Fatalf("expected port 6379, got %v", svc.FilePorts)
+	}
+}
+
+func TestParseServiceFileDefaultsIDToPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filelistener")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeServiceFile(t, dir, "noid.json", `{"hosts": {"main": "10.0.0.2"}, "ports": [{"Port": 80}]}`)
+
+	svc, err := parseServiceFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if svc.FileID != ID(path) {
+		t.Fatalf("expected id to default to path %s, got %s", path, svc.FileID)
+	}
+}
+
+func newTestFileListener() (*FileListener, chan Service, chan Service) {
+	newSvc := make(chan Service, 10)
+	delSvc := make(chan Service, 10)
+
+	return &FileListener{
+		newSvc:   newSvc,
+		delSvc:   delSvc,
+		services: make(map[string]*FileService),
+	}, newSvc, delSvc
+}
+
+func TestFileListenerLoadAndNotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filelistener")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeServiceFile(t, dir, "redis.yaml", `id: my-redis`)
+
+	l, newSvc, _ := newTestFileListener()
+	ctx := context.Background()
+	l.loadAndNotify(ctx, path)
+
+	select {
+	case got := <-newSvc:
+		if got.GetID() != "my-redis" {
+			t.Fatalf("expected newSvc for my-redis, got %s", got.GetID())
+		}
+	default:
+		t.Fatal("expected a newSvc event, got none")
+	}
+}
+
+func TestFileListenerReloadEmitsDelThenNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filelistener")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeServiceFile(t, dir, "redis.yaml", `id: my-redis`)
+
+	l, newSvc, delSvc := newTestFileListener()
+	ctx := context.Background()
+	l.loadAndNotify(ctx, path)
+	<-newSvc
+
+	writeServiceFile(t, dir, "redis.yaml", `id: my-redis-v2`)
+	l.loadAndNotify(ctx, path)
+
+	select {
+	case got := <-delSvc:
+		if got.GetID() != "my-redis" {
+			t.Fatalf("expected delSvc for the old id, got %s", got.GetID())
+		}
+	default:
+		t.Fatal("expected a delSvc event for the replaced service, got none")
+	}
+
+	select {
+	case got := <-newSvc:
+		if got.GetID() != "my-redis-v2" {
+			t.Fatalf("expected newSvc for the new id, got %s", got.GetID())
+		}
+	default:
+		t.Fatal("expected a newSvc event for the replaced service, got none")
+	}
+}
+
+// TestFileListenerLoadAndNotifyUnblocksOnCancel guards against the bug
+// fixed in chunk0-2: a loadAndNotify blocked sending on newSvc must return
+// as soon as its context is canceled, instead of hanging forever when
+// nothing is draining the channel.
+func TestFileListenerLoadAndNotifyUnblocksOnCancel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filelistener")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeServiceFile(t, dir, "redis.yaml", `id: my-redis`)
+
+	// Unbuffered so loadAndNotify blocks until someone reads or ctx is done.
+	newSvc := make(chan Service)
+	l := &FileListener{
+		newSvc:   newSvc,
+		delSvc:   make(chan Service),
+		services: make(map[string]*FileService),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		l.loadAndNotify(ctx, path)
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("loadAndNotify did not return after its context was canceled")
+	}
+}